@@ -1,7 +1,7 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -10,41 +10,27 @@ import (
 	"os"
 	"strings"
 	"time"
-)
-
-type ollamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
-}
-
-type ollamaResponse struct {
-	Response string `json:"response"`
-}
-
-type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type openAIRequest struct {
-	Model    string          `json:"model"`
-	Messages []openAIMessage `json:"messages"`
-}
 
-type openAIResponse struct {
-	Choices []struct {
-		Message openAIMessage `json:"message"`
-	} `json:"choices"`
-}
+	"github.com/googleapis/genai-toolbox/internal/chats"
+	"github.com/googleapis/genai-toolbox/internal/provider"
+	"github.com/googleapis/genai-toolbox/internal/router"
+)
 
 func main() {
-	provider := flag.String("provider", "ollama", "Self-hosted provider: ollama, openai-compatible, vllm, lmstudio, llamacpp, or textgen-webui")
-	baseURL := flag.String("base-url", "http://localhost:11434", "Provider base URL (ex: http://localhost:11434 for Ollama)")
+	providerName := flag.String("provider", "ollama", "LLM provider: ollama, openai-compatible, vllm, lmstudio, llamacpp, textgen-webui, anthropic, google, or cohere")
+	baseURL := flag.String("base-url", "http://localhost:11434", "Provider base URL (ex: http://localhost:11434 for Ollama); cloud providers default to their public API")
+	apiKey := flag.String("api-key", "", "API key for a hosted provider (falls back to the provider's conventional env var, e.g. ANTHROPIC_API_KEY)")
 	model := flag.String("model", "llama3", "Model name hosted by your provider")
 	timeout := flag.Duration("timeout", 30*time.Second, "HTTP timeout for the request")
-	prompt := flag.String("prompt", "", "Prompt to send to your self-hosted model")
-	promptFile := flag.String("prompt-file", "", "Path to a prompt file to send to your self-hosted model")
+	prompt := flag.String("prompt", "", "Prompt to send to your model")
+	promptFile := flag.String("prompt-file", "", "Path to a prompt file to send to your model")
+	stream := flag.Bool("stream", false, "Stream the response incrementally instead of waiting for the full reply")
+	chatDir := flag.String("chat-dir", "", "Directory for persisting chat history (enables --session-id when set)")
+	encryptionKeyFile := flag.String("encryption-key", "", "Path to a file holding the chat storage master key; when set, chat history is encrypted at rest")
+	encryptionKeyEnv := flag.String("encryption-key-env", "", "Name of an environment variable holding the chat storage master key, checked when --encryption-key is unset")
+	sessionID := flag.String("session-id", "", "Session ID to load prior turns from and append this turn to")
+	system := flag.String("system", "", "System prompt recorded at the start of a new session")
+	configPath := flag.String("config", "", "Path to a YAML routes config for multi-provider fallback (overrides --provider/--base-url/--model/--api-key)")
 	flag.Parse()
 
 	if strings.TrimSpace(*prompt) == "" && strings.TrimSpace(*promptFile) == "" {
@@ -70,127 +56,291 @@ func main() {
 		}
 	}
 
-	client := &http.Client{Timeout: *timeout}
+	var name string
+	if *configPath == "" {
+		name = provider.CanonicalName(*providerName)
+		if name == "" {
+			fmt.Fprintf(os.Stderr, "unsupported provider %q (supported: %s)\n", *providerName, strings.Join(provider.Names(), ", "))
+			os.Exit(1)
+		}
+	}
 
-	var responseText string
-	var err error
+	ctx := context.Background()
 
-	switch normalizeProvider(*provider) {
-	case "ollama":
-		responseText, err = callOllama(client, *baseURL, *model, finalPrompt)
-	case "openai-compatible":
-		responseText, err = callOpenAICompatible(client, *baseURL, *model, finalPrompt)
-	default:
-		err = fmt.Errorf("unsupported provider %q (supported: %s)", *provider, supportedProviders())
+	encryption, err := resolveChatEncryption(*encryptionKeyFile, *encryptionKeyEnv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error resolving chat encryption key:", err)
+		os.Exit(1)
 	}
 
+	store, err := chats.NewStore(*chatDir, encryption)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
+		fmt.Fprintln(os.Stderr, "error initializing chat store:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println(responseText)
-}
-
-func normalizeProvider(provider string) string {
-	switch strings.ToLower(strings.TrimSpace(provider)) {
-	case "ollama":
-		return "ollama"
-	case "openai-compatible", "openai", "openai-compatible-chat":
-		return "openai-compatible"
-	case "vllm":
-		return "openai-compatible"
-	case "lmstudio", "lm-studio":
-		return "openai-compatible"
-	case "llamacpp", "llama.cpp", "llama-cpp":
-		return "openai-compatible"
-	case "textgen-webui", "text-generation-webui", "oobabooga":
-		return "openai-compatible"
-	default:
-		return ""
+	var history []provider.Message
+	if store != nil && *sessionID != "" {
+		chat, err := store.LoadSession(*sessionID)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error loading session:", err)
+			os.Exit(1)
+		}
+		history, err = messagesFromChat(chat)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error replaying session:", err)
+			os.Exit(1)
+		}
+		if len(history) == 0 && strings.TrimSpace(*system) != "" {
+			systemMessage := provider.Message{Role: "system", Content: strings.TrimSpace(*system)}
+			recordChatMessage(ctx, store, *sessionID, chats.DirectionSystem, systemMessage, nil)
+			history = append(history, systemMessage)
+		}
 	}
-}
 
-func supportedProviders() string {
-	return "ollama, openai-compatible, vllm, lmstudio, llamacpp, textgen-webui"
-}
+	recordChatMessage(ctx, store, *sessionID, chats.DirectionRequest, provider.Message{Role: "user", Content: finalPrompt}, nil)
+
+	client := &http.Client{Timeout: *timeout}
+	registerProviders(client)
+
+	messages := append(append([]provider.Message{}, history...), provider.Message{Role: "user", Content: finalPrompt})
+
+	var responseText string
+	var usage provider.Usage
+	if *configPath != "" {
+		cfg, err := router.LoadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		rt := router.New(cfg.Routes, cfg.Cooldown)
+		responseText, usage, err = dispatchWithFallback(ctx, rt, store, *sessionID, messages, *stream)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+	} else {
+		p, err := provider.Get(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+
+		// Hosted providers only use --base-url when it was set explicitly;
+		// otherwise they fall back to their public API.
+		requestBaseURL := *baseURL
+		if name != "ollama" && name != "openai-compatible" {
+			requestBaseURL = ""
+			flag.Visit(func(f *flag.Flag) {
+				if f.Name == "base-url" {
+					requestBaseURL = *baseURL
+				}
+			})
+		}
 
-func callOllama(client *http.Client, baseURL, model, prompt string) (string, error) {
-	payload := ollamaRequest{
-		Model:  model,
-		Prompt: prompt,
-		Stream: false,
+		req := provider.Request{
+			Model:    *model,
+			Messages: messages,
+			APIKey:   provider.ResolveAPIKey(name, *apiKey),
+			BaseURL:  requestBaseURL,
+		}
+
+		if *stream {
+			var assembled strings.Builder
+			usage, err = p.Stream(ctx, req, io.MultiWriter(os.Stdout, &assembled))
+			responseText = assembled.String()
+		} else {
+			responseText, usage, err = p.Complete(ctx, req)
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
 	}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("marshal ollama request: %w", err)
+
+	recordChatMessage(ctx, store, *sessionID, chats.DirectionResponse, provider.Message{Role: "assistant", Content: responseText}, toChatsUsage(usage))
+
+	if *stream {
+		fmt.Println()
+	} else {
+		fmt.Println(responseText)
 	}
+}
 
-	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(baseURL, "/")+"/api/generate", bytes.NewBuffer(body))
-	if err != nil {
-		return "", fmt.Errorf("build ollama request: %w", err)
+// dispatchWithFallback walks rt's routes, attempting each in turn and
+// recording a fallback notification whenever a route's failure causes the
+// next one to be tried. It gives up once no route is currently healthy, and
+// returns at once on an error that provider.Retryable rejects.
+func dispatchWithFallback(ctx context.Context, rt *router.Router, store *chats.Store, sessionID string, messages []provider.Message, stream bool) (string, provider.Usage, error) {
+	var lastErr error
+	failedFrom := ""
+	for {
+		route, ok := rt.Next()
+		if !ok {
+			if lastErr != nil {
+				return "", provider.Usage{}, fmt.Errorf("no healthy provider available: %w", lastErr)
+			}
+			return "", provider.Usage{}, fmt.Errorf("no healthy provider available")
+		}
+		if failedFrom != "" {
+			recordFallback(ctx, store, sessionID, failedFrom, route.Name, lastErr)
+		}
+
+		p, err := provider.Get(route.Name)
+		if err != nil {
+			return "", provider.Usage{}, err
+		}
+
+		req := provider.Request{
+			Model:    route.Model,
+			Messages: messages,
+			APIKey:   provider.ResolveAPIKey(route.Name, route.APIKey),
+			BaseURL:  route.BaseURL,
+		}
+
+		var responseText string
+		var usage provider.Usage
+		if stream {
+			var assembled strings.Builder
+			usage, err = p.Stream(ctx, req, io.MultiWriter(os.Stdout, &assembled))
+			responseText = assembled.String()
+		} else {
+			responseText, usage, err = p.Complete(ctx, req)
+		}
+
+		if err == nil {
+			return responseText, usage, nil
+		}
+		if !provider.Retryable(err) {
+			return "", provider.Usage{}, err
+		}
+
+		rt.MarkFailure(route)
+		failedFrom = route.Name
+		lastErr = err
 	}
-	req.Header.Set("Content-Type", "application/json")
+}
 
-	resp, err := client.Do(req)
+// recordFallback persists a routing notification so ExportAll captures why a
+// session moved from one provider to another.
+func recordFallback(ctx context.Context, store *chats.Store, sessionID, from, to string, reason error) {
+	if store == nil || sessionID == "" {
+		return
+	}
+	payload, err := json.Marshal(struct {
+		Event  string `json:"event"`
+		From   string `json:"from"`
+		To     string `json:"to"`
+		Reason string `json:"reason"`
+	}{Event: "fallback", From: from, To: to, Reason: reason.Error()})
 	if err != nil {
-		return "", fmt.Errorf("send ollama request: %w", err)
+		fmt.Fprintln(os.Stderr, "warning: unable to marshal fallback notification:", err)
+		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		errBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ollama error (%d): %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+	if err := store.RecordMessage(ctx, sessionID, "", chats.DirectionNotification, payload, nil); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: unable to record fallback notification:", err)
 	}
+}
 
-	var decoded ollamaResponse
-	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
-		return "", fmt.Errorf("decode ollama response: %w", err)
+// resolveChatEncryption returns the chats.Encryption to pass to NewStore,
+// derived from whichever of keyFile or keyEnv the caller set. keyFile is
+// read as a literal file path and keyEnv as a literal environment variable
+// name - neither falls back to the other's interpretation, so a file that
+// happens to share a name with the configured env var can't silently win.
+// Neither set means chat history is stored in plain JSONL, as before this
+// flag existed.
+func resolveChatEncryption(keyFile, keyEnv string) (chats.Encryption, error) {
+	var masterKey []byte
+	switch {
+	case keyFile != "":
+		key, err := chats.ResolveMasterKeyFromFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		masterKey = key
+	case keyEnv != "":
+		key, err := chats.ResolveMasterKeyFromEnv(keyEnv)
+		if err != nil {
+			return nil, err
+		}
+		masterKey = key
+	default:
+		return nil, nil
 	}
+	return chats.NewAESGCMEncryption(masterKey)
+}
 
-	return decoded.Response, nil
+// registerProviders populates the provider registry with every built-in
+// backend, each sharing the CLI's configured HTTP client.
+func registerProviders(client *http.Client) {
+	provider.Register(provider.NewOllama(client))
+	provider.Register(provider.NewOpenAICompatible(client))
+	provider.Register(provider.NewAnthropic(client))
+	provider.Register(provider.NewGoogle(client))
+	provider.Register(provider.NewCohere(client))
 }
 
-func callOpenAICompatible(client *http.Client, baseURL, model, prompt string) (string, error) {
-	payload := openAIRequest{
-		Model: model,
-		Messages: []openAIMessage{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-	}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("marshal openai-compatible request: %w", err)
+// recordChatMessage persists msg under direction if a chat store and session
+// ID are configured, attaching usage when the caller has it. Recording
+// failures are reported to stderr but never abort the CLI, since a reply was
+// already produced.
+func recordChatMessage(ctx context.Context, store *chats.Store, sessionID, direction string, msg provider.Message, usage *chats.Usage) {
+	if store == nil || sessionID == "" {
+		return
 	}
-
-	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(baseURL, "/")+"/v1/chat/completions", bytes.NewBuffer(body))
+	payload, err := json.Marshal(msg)
 	if err != nil {
-		return "", fmt.Errorf("build openai-compatible request: %w", err)
+		fmt.Fprintln(os.Stderr, "warning: unable to marshal chat message:", err)
+		return
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("send openai-compatible request: %w", err)
+	if err := store.RecordMessage(ctx, sessionID, "", direction, payload, usage); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: unable to record chat message:", err)
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		errBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("openai-compatible error (%d): %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+// toChatsUsage converts a provider.Usage into the chats package's Usage,
+// returning nil when the provider didn't report any counts so RecordMessage
+// omits the field entirely rather than persisting a misleading zero.
+func toChatsUsage(u provider.Usage) *chats.Usage {
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 && u.TotalTokens == 0 {
+		return nil
 	}
-
-	var decoded openAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
-		return "", fmt.Errorf("decode openai-compatible response: %w", err)
+	return &chats.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
 	}
+}
 
-	if len(decoded.Choices) == 0 {
-		return "", fmt.Errorf("openai-compatible response missing choices")
+// messagesFromChat reconstructs the ordered []provider.Message history for a
+// replayed session, inferring each role from the recorded Direction rather
+// than trusting the stored payload's own role field.
+func messagesFromChat(chat chats.Chat) ([]provider.Message, error) {
+	messages := make([]provider.Message, 0, len(chat.Messages))
+	for _, recorded := range chat.Messages {
+		role := roleForDirection(recorded.Direction)
+		if role == "" {
+			continue
+		}
+		var decoded provider.Message
+		if err := json.Unmarshal(recorded.Payload, &decoded); err != nil {
+			return nil, fmt.Errorf("decode recorded chat message: %w", err)
+		}
+		messages = append(messages, provider.Message{Role: role, Content: decoded.Content})
 	}
+	return messages, nil
+}
 
-	return decoded.Choices[0].Message.Content, nil
+func roleForDirection(direction string) string {
+	switch direction {
+	case chats.DirectionSystem:
+		return "system"
+	case chats.DirectionRequest:
+		return "user"
+	case chats.DirectionResponse:
+		return "assistant"
+	default:
+		return ""
+	}
 }
@@ -0,0 +1,264 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chats
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DirectionEncrypted marks a Message whose line could not be opened: either
+// the Store reading it has no Encryption configured, or it does but the
+// line won't decrypt under the configured key (most commonly because the
+// master key rotated between when the line was written and when it's being
+// read back, e.g. after ArchiveSession merges files written under different
+// keys). Every field but SessionID and Payload is unknown in this case:
+// Payload holds the raw wrapper JSON verbatim so callers such as ExportAll
+// can still copy it somewhere safe without ever seeing the plaintext.
+const DirectionEncrypted = "encrypted"
+
+// encryptionWrapperVersion is the "v" field Seal writes and Open/isWrapped
+// check for, so a future incompatible wrapper shape can be rejected instead
+// of silently mis-decoded.
+const encryptionWrapperVersion = 1
+
+// Encryption seals and opens the single-line JSON a Store writes per
+// message, keyed per session so compromising one session's key doesn't
+// expose any other session's history.
+type Encryption interface {
+	// Seal encrypts plaintext for sessionID, returning the wrapper line to
+	// write in its place.
+	Seal(sessionID string, plaintext []byte) ([]byte, error)
+	// Open decrypts a wrapper line previously returned by Seal for
+	// sessionID.
+	Open(sessionID string, ciphertext []byte) ([]byte, error)
+}
+
+// encryptedLine is the on-disk shape of a sealed message line: a JSON object
+// so the file stays newline-delimited, carrying just enough to open it again
+// plus an alg tag so a future key rotation can tell sealed lines apart.
+type encryptedLine struct {
+	V     int    `json:"v"`
+	Alg   string `json:"alg"`
+	Nonce string `json:"nonce"`
+	CT    string `json:"ct"`
+}
+
+const aesGCMHKDFAlg = "aes-256-gcm-hkdf-sha256"
+
+// aesGCMEncryption is the default Encryption: AES-256-GCM with a per-session
+// key derived from a single master key via HKDF-SHA256, using the sanitized
+// session ID as the derivation's info parameter.
+type aesGCMEncryption struct {
+	masterKey []byte
+}
+
+// NewAESGCMEncryption returns an Encryption that derives per-session keys
+// from masterKey. masterKey can be any length and any quality of randomness
+// HKDF's extract step can still be fed; ResolveMasterKeyFromFile,
+// ResolveMasterKeyFromEnv, or ResolveMasterKey can obtain one.
+func NewAESGCMEncryption(masterKey []byte) (Encryption, error) {
+	if len(masterKey) == 0 {
+		return nil, fmt.Errorf("master key is required")
+	}
+	return &aesGCMEncryption{masterKey: masterKey}, nil
+}
+
+func (e *aesGCMEncryption) Seal(sessionID string, plaintext []byte) ([]byte, error) {
+	gcm, err := e.gcm(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("unable to generate nonce: %w", err)
+	}
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+	return json.Marshal(encryptedLine{
+		V:     encryptionWrapperVersion,
+		Alg:   aesGCMHKDFAlg,
+		Nonce: base64.StdEncoding.EncodeToString(nonce),
+		CT:    base64.StdEncoding.EncodeToString(ciphertext),
+	})
+}
+
+func (e *aesGCMEncryption) Open(sessionID string, ciphertext []byte) ([]byte, error) {
+	var wrapped encryptedLine
+	if err := json.Unmarshal(ciphertext, &wrapped); err != nil {
+		return nil, fmt.Errorf("unable to decode encrypted chat message: %w", err)
+	}
+	if wrapped.Alg != aesGCMHKDFAlg {
+		return nil, fmt.Errorf("unsupported chat encryption algorithm %q", wrapped.Alg)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(wrapped.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode chat message nonce: %w", err)
+	}
+	ct, err := base64.StdEncoding.DecodeString(wrapped.CT)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode chat message ciphertext: %w", err)
+	}
+	gcm, err := e.gcm(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ct, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt chat message: %w", err)
+	}
+	return plaintext, nil
+}
+
+func (e *aesGCMEncryption) gcm(sessionID string) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, e.masterKey, nil, []byte(sanitizeSessionID(sessionID))), key); err != nil {
+		return nil, fmt.Errorf("unable to derive chat session key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create chat cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create chat cipher mode: %w", err)
+	}
+	return gcm, nil
+}
+
+// ResolveMasterKey reads the encryption master key from source: a path to an
+// existing file, or failing that the name of an environment variable
+// holding the key. Only use this when source's kind (file vs. env var) is
+// genuinely unknown to the caller; when a caller already knows which one it
+// has (e.g. two distinct CLI flags), call ResolveMasterKeyFromFile or
+// ResolveMasterKeyFromEnv directly so a same-named file can't shadow the
+// env var the caller actually asked for, or vice versa.
+func ResolveMasterKey(source string) ([]byte, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return nil, fmt.Errorf("master key source is required")
+	}
+	if info, err := os.Stat(source); err == nil && !info.IsDir() {
+		return ResolveMasterKeyFromFile(source)
+	}
+	return ResolveMasterKeyFromEnv(source)
+}
+
+// ResolveMasterKeyFromFile reads the encryption master key from the file at
+// path, with no fallback to an environment variable.
+func ResolveMasterKeyFromFile(path string) ([]byte, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("master key file path is required")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read master key file: %w", err)
+	}
+	return []byte(strings.TrimSpace(string(data))), nil
+}
+
+// ResolveMasterKeyFromEnv reads the encryption master key from the
+// environment variable named name, with no fallback to reading name as a
+// file path.
+func ResolveMasterKeyFromEnv(name string) ([]byte, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("master key environment variable name is required")
+	}
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, fmt.Errorf("master key environment variable %q is not set", name)
+	}
+	return []byte(value), nil
+}
+
+// isWrapped reports whether line is a sealed message written by Seal, as
+// opposed to a plain Message line.
+func isWrapped(line []byte) bool {
+	var peek struct {
+		V  int    `json:"v"`
+		CT string `json:"ct"`
+	}
+	if err := json.Unmarshal(line, &peek); err != nil {
+		return false
+	}
+	return peek.V == encryptionWrapperVersion && peek.CT != ""
+}
+
+// encodeLine marshals msg and, if s has an Encryption configured, seals the
+// result under sessionID so the line written to disk is opaque.
+func (s *Store) encodeLine(sessionID string, msg Message) ([]byte, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal message: %w", err)
+	}
+	if s.encryption == nil {
+		return data, nil
+	}
+	sealed, err := s.encryption.Seal(sessionID, data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to seal chat message: %w", err)
+	}
+	return sealed, nil
+}
+
+// decodeLine reverses encodeLine. A wrapped line that arrives while s has no
+// Encryption configured, or that s.encryption can't open under its current
+// key (e.g. the master key rotated since the line was written), cannot be
+// opened at all; decodeLine returns it as an opaque DirectionEncrypted
+// placeholder instead of failing outright, so ExportAll, Stream, and
+// friends keep working against the rest of the session rather than losing
+// the whole read to one unreadable line.
+func (s *Store) decodeLine(sessionID string, line []byte) (Message, error) {
+	if !isWrapped(line) {
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return Message{}, fmt.Errorf("unable to decode chat message: %w", err)
+		}
+		return msg, nil
+	}
+
+	if s.encryption == nil {
+		return Message{
+			SessionID: sessionID,
+			Direction: DirectionEncrypted,
+			Payload:   json.RawMessage(append([]byte(nil), line...)),
+		}, nil
+	}
+
+	plaintext, err := s.encryption.Open(sessionID, line)
+	if err != nil {
+		return Message{
+			SessionID: sessionID,
+			Direction: DirectionEncrypted,
+			Payload:   json.RawMessage(append([]byte(nil), line...)),
+		}, nil
+	}
+	var msg Message
+	if err := json.Unmarshal(plaintext, &msg); err != nil {
+		return Message{}, fmt.Errorf("unable to decode chat message: %w", err)
+	}
+	return msg, nil
+}
@@ -17,6 +17,9 @@ package chats
 import (
 	"bufio"
 	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -32,14 +35,30 @@ const (
 	DirectionRequest      = "request"
 	DirectionNotification = "notification"
 	DirectionResponse     = "response"
+	DirectionSystem       = "system"
 )
 
+// indexRecordSize is the on-disk width of one index entry: an 8-byte
+// offset, a 4-byte length, and an 8-byte nanosecond timestamp.
+const indexRecordSize = 8 + 4 + 8
+
 type Message struct {
+	ID        string          `json:"id,omitempty"`
+	ParentID  string          `json:"parentId,omitempty"`
 	SessionID string          `json:"sessionId"`
 	Toolset   string          `json:"toolset,omitempty"`
 	Direction string          `json:"direction"`
 	Timestamp time.Time       `json:"timestamp"`
 	Payload   json.RawMessage `json:"payload"`
+	Usage     *Usage          `json:"usage,omitempty"`
+}
+
+// Usage records the token counts a provider reported for a response, so
+// exports can add up cost or rate-limit usage per toolset.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens,omitempty"`
+	CompletionTokens int `json:"completionTokens,omitempty"`
+	TotalTokens      int `json:"totalTokens,omitempty"`
 }
 
 type Chat struct {
@@ -48,20 +67,83 @@ type Chat struct {
 	Messages  []Message `json:"messages"`
 }
 
+// Branches groups c.Messages into root-to-leaf chains, one per leaf message
+// (a message that is no other message's ParentID), by walking ParentID
+// links backward. A session that has never been forked produces a single
+// branch containing every message in order.
+func (c Chat) Branches() [][]Message {
+	byID := make(map[string]Message, len(c.Messages))
+	hasChild := make(map[string]bool, len(c.Messages))
+	for _, msg := range c.Messages {
+		byID[msg.ID] = msg
+		if msg.ParentID != "" {
+			hasChild[msg.ParentID] = true
+		}
+	}
+
+	var branches [][]Message
+	for _, msg := range c.Messages {
+		if hasChild[msg.ID] {
+			continue
+		}
+		chain := []Message{msg}
+		for current := msg; current.ParentID != ""; {
+			parent, ok := byID[current.ParentID]
+			if !ok {
+				break
+			}
+			chain = append(chain, parent)
+			current = parent
+		}
+		for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+			chain[i], chain[j] = chain[j], chain[i]
+		}
+		branches = append(branches, chain)
+	}
+	return branches
+}
+
+// chatExport is the per-chat shape ExportAll writes: Chat plus its
+// precomputed Branches, so readers that only look at "messages" keep
+// working unchanged while readers that understand forking get "branches"
+// too.
+type chatExport struct {
+	SessionID string      `json:"sessionId"`
+	Archived  bool        `json:"archived"`
+	Messages  []Message   `json:"messages"`
+	Branches  [][]Message `json:"branches"`
+}
+
+// Export describes the JSON document ExportAll streams to disk.
 type Export struct {
-	ExportedAt    time.Time `json:"exportedAt"`
-	ActiveChats   int       `json:"activeChats"`
-	ArchivedChats int       `json:"archivedChats"`
-	Chats         []Chat    `json:"chats"`
+	ExportedAt            time.Time `json:"exportedAt"`
+	ActiveChats           int       `json:"activeChats"`
+	ArchivedChats         int       `json:"archivedChats"`
+	Chats                 []Chat    `json:"chats"`
+	TotalPromptTokens     int       `json:"totalPromptTokens"`
+	TotalCompletionTokens int       `json:"totalCompletionTokens"`
+}
+
+// indexRecord locates one message within its session's .jsonl file, letting
+// Stream seek directly to it instead of scanning the whole file.
+type indexRecord struct {
+	Offset    uint64
+	Length    uint32
+	Timestamp int64 // UnixNano
 }
 
 type Store struct {
-	rootDir string
-	mu      sync.Mutex
-	now     func() time.Time
+	rootDir    string
+	mu         sync.Mutex
+	now        func() time.Time
+	encryption Encryption
 }
 
-func NewStore(rootDir string) (*Store, error) {
+// NewStore returns a Store rooted at rootDir. When encryption is non-nil,
+// every message written through it is sealed at rest and every message read
+// back is opened through the same Encryption; pass nil to store plaintext
+// JSONL as before.
+func NewStore(rootDir string, encryption Encryption) (*Store, error) {
 	if strings.TrimSpace(rootDir) == "" {
 		return nil, nil
 	}
@@ -71,31 +153,47 @@ func NewStore(rootDir string) (*Store, error) {
 	if err := os.MkdirAll(filepath.Join(rootDir, "archived"), 0o700); err != nil {
 		return nil, fmt.Errorf("unable to create chat archive directory: %w", err)
 	}
-	return &Store{rootDir: rootDir, now: time.Now}, nil
+	return &Store{rootDir: rootDir, now: time.Now, encryption: encryption}, nil
 }
 
-func (s *Store) RecordMessage(ctx context.Context, sessionID, toolset, direction string, payload []byte) error {
+func (s *Store) RecordMessage(ctx context.Context, sessionID, toolset, direction string, payload []byte, usage *Usage) error {
 	if s == nil {
 		return nil
 	}
 	if sessionID == "" {
 		return fmt.Errorf("session id is required")
 	}
+
+	id, err := newMessageID()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parentID, err := s.latestMessageIDLocked(sessionID)
+	if err != nil {
+		return err
+	}
+
 	message := Message{
+		ID:        id,
+		ParentID:  parentID,
 		SessionID: sessionID,
 		Toolset:   toolset,
 		Direction: direction,
 		Timestamp: s.now(),
 		Payload:   json.RawMessage(payload),
+		Usage:     usage,
 	}
 
-	data, err := json.Marshal(message)
+	data, err := s.encodeLine(sessionID, message)
 	if err != nil {
-		return fmt.Errorf("unable to marshal message: %w", err)
+		return err
 	}
+	line := append(data, '\n')
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
 	path := s.sessionPath(sessionID, false)
 	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
 	if err != nil {
@@ -103,16 +201,411 @@ func (s *Store) RecordMessage(ctx context.Context, sessionID, toolset, direction
 	}
 	defer file.Close()
 
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat chat session file: %w", err)
+	}
+	offset := uint64(info.Size())
+
 	writer := bufio.NewWriter(file)
-	if _, err := writer.Write(append(data, '\n')); err != nil {
+	if _, err := writer.Write(line); err != nil {
 		return fmt.Errorf("unable to write chat message: %w", err)
 	}
 	if err := writer.Flush(); err != nil {
 		return fmt.Errorf("unable to flush chat message: %w", err)
 	}
+
+	idxFile, err := os.OpenFile(s.indexPath(sessionID, false), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open chat index file: %w", err)
+	}
+	defer idxFile.Close()
+	if err := writeIndexRecord(idxFile, indexRecord{Offset: offset, Length: uint32(len(line)), Timestamp: message.Timestamp.UnixNano()}); err != nil {
+		return fmt.Errorf("unable to write chat index record: %w", err)
+	}
+	return nil
+}
+
+// StreamOptions narrows a Stream call to a time range and/or a maximum
+// number of messages.
+type StreamOptions struct {
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+// Stream emits sessionID's messages matching opts over the returned channel,
+// using the session's index to seek directly to each matching message
+// rather than loading the whole session file into memory. The channel is
+// closed once every matching message has been sent, ctx is cancelled, or a
+// read error occurs.
+func (s *Store) Stream(ctx context.Context, sessionID string, opts StreamOptions) (<-chan Message, error) {
+	if s == nil {
+		return nil, fmt.Errorf("chat storage is not configured")
+	}
+	if sessionID == "" {
+		return nil, fmt.Errorf("session id is required")
+	}
+
+	records, err := readIndex(s.indexPath(sessionID, false))
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(s.sessionPath(sessionID, false))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			out := make(chan Message)
+			close(out)
+			return out, nil
+		}
+		return nil, fmt.Errorf("unable to open chat session file: %w", err)
+	}
+
+	out := make(chan Message)
+	go func() {
+		defer file.Close()
+		defer close(out)
+
+		emitted := 0
+		for _, rec := range records {
+			if opts.Limit > 0 && emitted >= opts.Limit {
+				return
+			}
+			if !opts.Since.IsZero() && rec.Timestamp < opts.Since.UnixNano() {
+				continue
+			}
+			if !opts.Until.IsZero() && rec.Timestamp > opts.Until.UnixNano() {
+				continue
+			}
+
+			buf := make([]byte, rec.Length)
+			if _, err := file.ReadAt(buf, int64(rec.Offset)); err != nil {
+				return
+			}
+			msg, err := s.decodeLine(sessionID, buf)
+			if err != nil {
+				return
+			}
+
+			select {
+			case out <- msg:
+				emitted++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Compact rewrites sessionID's file: it first collapses runs of consecutive
+// notification messages down to the latest one in each run, then drops
+// non-notification messages older than retention (messages are kept
+// regardless of age when retention is zero). A collapsed notification
+// already represents the current state of its run, so it survives the age
+// filter even if the run itself predates the cutoff. The session file and
+// its index are swapped into place atomically via rename once the rewrite
+// succeeds.
+func (s *Store) Compact(sessionID string, retention time.Duration) error {
+	if s == nil {
+		return nil
+	}
+	if sessionID == "" {
+		return fmt.Errorf("session id is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.sessionPath(sessionID, false)
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("unable to stat chat session file: %w", err)
+	}
+
+	messages, err := s.readMessages(sessionID, path)
+	if err != nil {
+		return err
+	}
+
+	var cutoff time.Time
+	if retention > 0 {
+		cutoff = s.now().Add(-retention)
+	}
+
+	// Collapse consecutive notification runs first, over the full,
+	// unfiltered message order, so a run's survivor is the one the age
+	// filter below considers rather than an intermediate one that age
+	// filtering might otherwise have preferred.
+	collapsed := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Direction == DirectionNotification && len(collapsed) > 0 && collapsed[len(collapsed)-1].Direction == DirectionNotification {
+			collapsed[len(collapsed)-1] = msg
+			continue
+		}
+		collapsed = append(collapsed, msg)
+	}
+
+	compacted := make([]Message, 0, len(collapsed))
+	for _, msg := range collapsed {
+		if !cutoff.IsZero() && msg.Direction != DirectionNotification && msg.Timestamp.Before(cutoff) {
+			continue
+		}
+		compacted = append(compacted, msg)
+	}
+
+	tmpPath := path + ".compact"
+	tmpIdxPath := s.indexPath(sessionID, false) + ".compact"
+	if err := s.writeSessionFile(tmpPath, tmpIdxPath, sessionID, compacted); err != nil {
+		_ = os.Remove(tmpPath)
+		_ = os.Remove(tmpIdxPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("unable to replace chat session file: %w", err)
+	}
+	if err := os.Rename(tmpIdxPath, s.indexPath(sessionID, false)); err != nil {
+		return fmt.Errorf("unable to replace chat index file: %w", err)
+	}
 	return nil
 }
 
+// Fork copies the chain of messages from sessionID's root up to and
+// including fromMessageID into a brand new session, returning the new
+// session's ID. The original session is left untouched, so the new session
+// is a branch point a caller can continue independently.
+func (s *Store) Fork(sessionID, fromMessageID string) (string, error) {
+	if s == nil {
+		return "", fmt.Errorf("chat storage is not configured")
+	}
+	if sessionID == "" {
+		return "", fmt.Errorf("session id is required")
+	}
+	if fromMessageID == "" {
+		return "", fmt.Errorf("message id is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages, err := s.readMessages(sessionID, s.sessionPath(sessionID, false))
+	if err != nil {
+		return "", err
+	}
+	prefix, err := prefixChain(messages, fromMessageID)
+	if err != nil {
+		return "", err
+	}
+
+	newSessionID, err := newForkSessionID()
+	if err != nil {
+		return "", err
+	}
+	forked := make([]Message, len(prefix))
+	for i, msg := range prefix {
+		msg.SessionID = newSessionID
+		forked[i] = msg
+	}
+
+	if err := s.writeSessionFile(s.sessionPath(newSessionID, false), s.indexPath(newSessionID, false), newSessionID, forked); err != nil {
+		return "", err
+	}
+	return newSessionID, nil
+}
+
+// EditAndFork forks sessionID up to and including messageID's parent (every
+// message before messageID on its chain), then appends newPayload as a new leaf taking messageID's place, returning
+// the new session's ID. The original session and message are left
+// untouched; the edit only exists on the new branch.
+func (s *Store) EditAndFork(sessionID, messageID string, newPayload []byte) (string, error) {
+	if s == nil {
+		return "", fmt.Errorf("chat storage is not configured")
+	}
+	if sessionID == "" {
+		return "", fmt.Errorf("session id is required")
+	}
+	if messageID == "" {
+		return "", fmt.Errorf("message id is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages, err := s.readMessages(sessionID, s.sessionPath(sessionID, false))
+	if err != nil {
+		return "", err
+	}
+
+	var original Message
+	found := false
+	for _, msg := range messages {
+		if msg.ID == messageID {
+			original = msg
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("message %q not found", messageID)
+	}
+
+	var prefix []Message
+	if original.ParentID != "" {
+		prefix, err = prefixChain(messages, original.ParentID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	newSessionID, err := newForkSessionID()
+	if err != nil {
+		return "", err
+	}
+	editedID, err := newMessageID()
+	if err != nil {
+		return "", err
+	}
+
+	forked := make([]Message, 0, len(prefix)+1)
+	for _, msg := range prefix {
+		msg.SessionID = newSessionID
+		forked = append(forked, msg)
+	}
+	forked = append(forked, Message{
+		ID:        editedID,
+		ParentID:  original.ParentID,
+		SessionID: newSessionID,
+		Toolset:   original.Toolset,
+		Direction: original.Direction,
+		Timestamp: s.now(),
+		Payload:   json.RawMessage(newPayload),
+	})
+
+	if err := s.writeSessionFile(s.sessionPath(newSessionID, false), s.indexPath(newSessionID, false), newSessionID, forked); err != nil {
+		return "", err
+	}
+	return newSessionID, nil
+}
+
+// latestMessageIDLocked returns the ID of the most recently recorded
+// message in sessionID, or "" if the session has no messages yet. Callers
+// must hold s.mu.
+func (s *Store) latestMessageIDLocked(sessionID string) (string, error) {
+	records, err := readIndex(s.indexPath(sessionID, false))
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		return "", nil
+	}
+	last := records[len(records)-1]
+
+	file, err := os.Open(s.sessionPath(sessionID, false))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("unable to open chat session file: %w", err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, last.Length)
+	if _, err := file.ReadAt(buf, int64(last.Offset)); err != nil {
+		return "", fmt.Errorf("unable to read chat session file: %w", err)
+	}
+	msg, err := s.decodeLine(sessionID, buf)
+	if err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+// prefixChain returns the chain of messages from the DAG's root down to and
+// including targetID, following ParentID links backward.
+func prefixChain(messages []Message, targetID string) ([]Message, error) {
+	byID := make(map[string]Message, len(messages))
+	for _, msg := range messages {
+		byID[msg.ID] = msg
+	}
+	target, ok := byID[targetID]
+	if !ok {
+		return nil, fmt.Errorf("message %q not found", targetID)
+	}
+
+	chain := []Message{target}
+	for current := target; current.ParentID != ""; {
+		parent, ok := byID[current.ParentID]
+		if !ok {
+			break
+		}
+		chain = append(chain, parent)
+		current = parent
+	}
+
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// newMessageID returns a random, practically-unique message ID.
+func newMessageID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("unable to generate message id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// newForkSessionID returns a random, practically-unique session ID prefixed
+// so forked sessions are recognizable in directory listings.
+func newForkSessionID() (string, error) {
+	id, err := newMessageID()
+	if err != nil {
+		return "", err
+	}
+	return "fork-" + id, nil
+}
+
+// LoadSession reads back the messages recorded so far for sessionID. A
+// session that has not recorded any messages yet is not an error: it
+// returns an empty Chat so callers can treat it as the start of a new
+// conversation.
+func (s *Store) LoadSession(sessionID string) (Chat, error) {
+	if s == nil {
+		return Chat{}, fmt.Errorf("chat storage is not configured")
+	}
+	if sessionID == "" {
+		return Chat{}, fmt.Errorf("session id is required")
+	}
+
+	path := s.sessionPath(sessionID, false)
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Chat{SessionID: sessionID}, nil
+		}
+		return Chat{}, fmt.Errorf("unable to stat chat session file: %w", err)
+	}
+
+	messages, err := s.readMessages(sessionID, path)
+	if err != nil {
+		return Chat{}, err
+	}
+	return Chat{SessionID: sessionID, Messages: messages}, nil
+}
+
+// ArchiveSession moves sessionID's file into the archive directory, merging
+// it onto an existing archive file if one is already there. The merge copies
+// encrypted lines byte-for-byte rather than re-sealing them: there is no way
+// to recover the key an earlier write used, so a line written under a since-
+// rotated master key cannot be translated to the current one. Reads of the
+// merged archive still succeed as a whole; decodeLine surfaces any line that
+// won't open under the current key as an opaque DirectionEncrypted message
+// instead of failing the entire session.
 func (s *Store) ArchiveSession(sessionID string) error {
 	if s == nil {
 		return nil
@@ -125,6 +618,9 @@ func (s *Store) ArchiveSession(sessionID string) error {
 
 	source := s.sessionPath(sessionID, false)
 	dest := s.sessionPath(sessionID, true)
+	sourceIdx := s.indexPath(sessionID, false)
+	destIdx := s.indexPath(sessionID, true)
+
 	if _, err := os.Stat(source); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return nil
@@ -133,12 +629,13 @@ func (s *Store) ArchiveSession(sessionID string) error {
 	}
 
 	if _, err := os.Stat(dest); err == nil {
-		if err := appendFile(dest, source); err != nil {
+		if err := appendIndexed(dest, destIdx, source, sourceIdx); err != nil {
 			return err
 		}
 		if err := os.Remove(source); err != nil {
 			return fmt.Errorf("unable to remove chat session file: %w", err)
 		}
+		_ = os.Remove(sourceIdx)
 		return nil
 	} else if !errors.Is(err, os.ErrNotExist) {
 		return fmt.Errorf("unable to stat chat archive file: %w", err)
@@ -147,9 +644,21 @@ func (s *Store) ArchiveSession(sessionID string) error {
 	if err := os.Rename(source, dest); err != nil {
 		return fmt.Errorf("unable to archive chat session file: %w", err)
 	}
+	if _, err := os.Stat(sourceIdx); err == nil {
+		if err := os.Rename(sourceIdx, destIdx); err != nil {
+			return fmt.Errorf("unable to archive chat index file: %w", err)
+		}
+	}
 	return nil
 }
 
+// ExportAll streams every chat, active and archived, into a single JSON
+// document at outputPath. Chats are read and encoded one at a time so the
+// full corpus is never held in memory at once, regardless of how many
+// sessions or messages have accumulated. Messages are decrypted using
+// s.encryption if one is configured; otherwise any encrypted messages are
+// written out as opaque DirectionEncrypted wrappers, so an export taken
+// without the master key stays safe to hand off as a backup.
 func (s *Store) ExportAll(ctx context.Context, outputPath string) error {
 	if s == nil {
 		return fmt.Errorf("chat storage is not configured")
@@ -158,41 +667,141 @@ func (s *Store) ExportAll(ctx context.Context, outputPath string) error {
 		return fmt.Errorf("output path is required")
 	}
 
-	activeChats, err := s.loadChats(ctx, false)
+	activeIDs, err := s.listSessions(false)
 	if err != nil {
 		return err
 	}
-	archivedChats, err := s.loadChats(ctx, true)
+	archivedIDs, err := s.listSessions(true)
 	if err != nil {
 		return err
 	}
 
-	export := Export{
-		ExportedAt:    s.now(),
-		ActiveChats:   len(activeChats),
-		ArchivedChats: len(archivedChats),
-		Chats:         append(activeChats, archivedChats...),
+	type sessionRef struct {
+		sessionID string
+		archived  bool
+	}
+	refs := make([]sessionRef, 0, len(activeIDs)+len(archivedIDs))
+	for _, id := range activeIDs {
+		refs = append(refs, sessionRef{sessionID: id})
+	}
+	for _, id := range archivedIDs {
+		refs = append(refs, sessionRef{sessionID: id, archived: true})
 	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].sessionID < refs[j].sessionID })
 
-	sort.Slice(export.Chats, func(i, j int) bool {
-		return export.Chats[i].SessionID < export.Chats[j].SessionID
-	})
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o700); err != nil {
+		return fmt.Errorf("unable to create export directory: %w", err)
+	}
+	file, err := os.OpenFile(outputPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to create export file: %w", err)
+	}
+	defer file.Close()
 
-	data, err := json.MarshalIndent(export, "", "  ")
+	writer := bufio.NewWriter(file)
+	exportedAt, err := json.Marshal(s.now())
 	if err != nil {
-		return fmt.Errorf("unable to marshal chat export: %w", err)
+		return fmt.Errorf("unable to marshal export time: %w", err)
+	}
+	if _, err := fmt.Fprintf(writer, "{\n  \"exportedAt\": %s,\n  \"activeChats\": %d,\n  \"archivedChats\": %d,\n  \"chats\": [\n", exportedAt, len(activeIDs), len(archivedIDs)); err != nil {
+		return fmt.Errorf("unable to write export header: %w", err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0o700); err != nil {
-		return fmt.Errorf("unable to create export directory: %w", err)
+	var totalPromptTokens, totalCompletionTokens int
+	encoder := json.NewEncoder(writer)
+	for i, ref := range refs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		messages, err := s.readMessages(ref.sessionID, s.sessionPath(ref.sessionID, ref.archived))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := writer.WriteString(",\n"); err != nil {
+				return fmt.Errorf("unable to write chat separator: %w", err)
+			}
+		}
+		chat := Chat{SessionID: ref.sessionID, Archived: ref.archived, Messages: messages}
+		if err := encoder.Encode(chatExport{
+			SessionID: chat.SessionID,
+			Archived:  chat.Archived,
+			Messages:  chat.Messages,
+			Branches:  chat.Branches(),
+		}); err != nil {
+			return fmt.Errorf("unable to encode chat %q: %w", ref.sessionID, err)
+		}
+		for _, msg := range messages {
+			if msg.Usage == nil {
+				continue
+			}
+			totalPromptTokens += msg.Usage.PromptTokens
+			totalCompletionTokens += msg.Usage.CompletionTokens
+		}
 	}
-	if err := os.WriteFile(outputPath, data, 0o600); err != nil {
-		return fmt.Errorf("unable to write export file: %w", err)
+
+	if _, err := fmt.Fprintf(writer, "  ],\n  \"totalPromptTokens\": %d,\n  \"totalCompletionTokens\": %d\n}\n", totalPromptTokens, totalCompletionTokens); err != nil {
+		return fmt.Errorf("unable to write export footer: %w", err)
+	}
+	return writer.Flush()
+}
+
+// UsageByToolset sums recorded token usage across every session, active and
+// archived, grouped by the toolset each message was recorded under.
+func (s *Store) UsageByToolset(ctx context.Context) (map[string]Usage, error) {
+	if s == nil {
+		return nil, fmt.Errorf("chat storage is not configured")
+	}
+
+	activeIDs, err := s.listSessions(false)
+	if err != nil {
+		return nil, err
+	}
+	archivedIDs, err := s.listSessions(true)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]Usage)
+	for _, id := range activeIDs {
+		if err := s.accumulateUsage(ctx, totals, id, s.sessionPath(id, false)); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range archivedIDs {
+		if err := s.accumulateUsage(ctx, totals, id, s.sessionPath(id, true)); err != nil {
+			return nil, err
+		}
+	}
+	return totals, nil
+}
+
+// accumulateUsage reads sessionID's messages and adds their Usage into
+// totals, keyed by each message's Toolset.
+func (s *Store) accumulateUsage(ctx context.Context, totals map[string]Usage, sessionID, path string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	messages, err := s.readMessages(sessionID, path)
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		if msg.Usage == nil {
+			continue
+		}
+		toolsetTotal := totals[msg.Toolset]
+		toolsetTotal.PromptTokens += msg.Usage.PromptTokens
+		toolsetTotal.CompletionTokens += msg.Usage.CompletionTokens
+		toolsetTotal.TotalTokens += msg.Usage.TotalTokens
+		totals[msg.Toolset] = toolsetTotal
 	}
 	return nil
 }
 
-func (s *Store) loadChats(ctx context.Context, archived bool) ([]Chat, error) {
+// listSessions returns the sorted session IDs present in the active or
+// archived directory, without reading any message content.
+func (s *Store) listSessions(archived bool) ([]string, error) {
 	dir := filepath.Join(s.rootDir, "active")
 	if archived {
 		dir = filepath.Join(s.rootDir, "archived")
@@ -206,23 +815,14 @@ func (s *Store) loadChats(ctx context.Context, archived bool) ([]Chat, error) {
 		return nil, fmt.Errorf("unable to read chat directory: %w", err)
 	}
 
-	chats := make([]Chat, 0, len(entries))
+	ids := make([]string, 0, len(entries))
 	for _, entry := range entries {
-		if ctx.Err() != nil {
-			return nil, ctx.Err()
-		}
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") {
 			continue
 		}
-		sessionID := strings.TrimSuffix(entry.Name(), ".jsonl")
-		path := filepath.Join(dir, entry.Name())
-		messages, err := readMessages(path)
-		if err != nil {
-			return nil, err
-		}
-		chats = append(chats, Chat{SessionID: sessionID, Archived: archived, Messages: messages})
+		ids = append(ids, strings.TrimSuffix(entry.Name(), ".jsonl"))
 	}
-	return chats, nil
+	return ids, nil
 }
 
 func (s *Store) sessionPath(sessionID string, archived bool) string {
@@ -234,6 +834,15 @@ func (s *Store) sessionPath(sessionID string, archived bool) string {
 	return filepath.Join(dir, cleanID+".jsonl")
 }
 
+func (s *Store) indexPath(sessionID string, archived bool) string {
+	cleanID := sanitizeSessionID(sessionID)
+	dir := filepath.Join(s.rootDir, "active")
+	if archived {
+		dir = filepath.Join(s.rootDir, "archived")
+	}
+	return filepath.Join(dir, cleanID+".idx")
+}
+
 func sanitizeSessionID(sessionID string) string {
 	return strings.Map(func(r rune) rune {
 		switch {
@@ -251,23 +860,119 @@ func sanitizeSessionID(sessionID string) string {
 	}, sessionID)
 }
 
-func appendFile(dest, source string) error {
-	data, err := os.ReadFile(source)
+// appendIndexed appends sourceDataPath onto destDataPath and sourceIdxPath's
+// records, offset by destDataPath's prior size, onto destIdxPath - keeping
+// the merged index consistent with the merged data file.
+func appendIndexed(destDataPath, destIdxPath, sourceDataPath, sourceIdxPath string) error {
+	destInfo, err := os.Stat(destDataPath)
+	if err != nil {
+		return fmt.Errorf("unable to stat chat archive file: %w", err)
+	}
+	baseOffset := uint64(destInfo.Size())
+
+	data, err := os.ReadFile(sourceDataPath)
 	if err != nil {
 		return fmt.Errorf("unable to read chat session file: %w", err)
 	}
-	file, err := os.OpenFile(dest, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	destFile, err := os.OpenFile(destDataPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
 	if err != nil {
 		return fmt.Errorf("unable to open chat archive file: %w", err)
 	}
-	defer file.Close()
-	if _, err := file.Write(data); err != nil {
+	defer destFile.Close()
+	if _, err := destFile.Write(data); err != nil {
 		return fmt.Errorf("unable to append chat archive file: %w", err)
 	}
+
+	records, err := readIndex(sourceIdxPath)
+	if err != nil {
+		return err
+	}
+	destIdxFile, err := os.OpenFile(destIdxPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open chat archive index file: %w", err)
+	}
+	defer destIdxFile.Close()
+	for _, rec := range records {
+		rec.Offset += baseOffset
+		if err := writeIndexRecord(destIdxFile, rec); err != nil {
+			return fmt.Errorf("unable to append chat archive index file: %w", err)
+		}
+	}
 	return nil
 }
 
-func readMessages(path string) ([]Message, error) {
+// writeSessionFile writes messages to dataPath and a matching fresh index
+// to idxPath, truncating both if they already exist. sessionID is used to
+// derive each line's encryption key when s.encryption is configured; it is
+// the new session's ID for Fork/EditAndFork and the existing session's ID
+// for Compact.
+func (s *Store) writeSessionFile(dataPath, idxPath, sessionID string, messages []Message) error {
+	dataFile, err := os.OpenFile(dataPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to create chat session file: %w", err)
+	}
+	defer dataFile.Close()
+	idxFile, err := os.OpenFile(idxPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to create chat index file: %w", err)
+	}
+	defer idxFile.Close()
+
+	writer := bufio.NewWriter(dataFile)
+	var offset uint64
+	for _, msg := range messages {
+		data, err := s.encodeLine(sessionID, msg)
+		if err != nil {
+			return err
+		}
+		line := append(data, '\n')
+		if _, err := writer.Write(line); err != nil {
+			return fmt.Errorf("unable to write chat message: %w", err)
+		}
+		if err := writeIndexRecord(idxFile, indexRecord{Offset: offset, Length: uint32(len(line)), Timestamp: msg.Timestamp.UnixNano()}); err != nil {
+			return fmt.Errorf("unable to write chat index record: %w", err)
+		}
+		offset += uint64(len(line))
+	}
+	return writer.Flush()
+}
+
+func writeIndexRecord(w *os.File, rec indexRecord) error {
+	var buf [indexRecordSize]byte
+	binary.BigEndian.PutUint64(buf[0:8], rec.Offset)
+	binary.BigEndian.PutUint32(buf[8:12], rec.Length)
+	binary.BigEndian.PutUint64(buf[12:20], uint64(rec.Timestamp))
+	_, err := w.Write(buf[:])
+	return err
+}
+
+func readIndex(path string) ([]indexRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read chat index file: %w", err)
+	}
+	if len(data)%indexRecordSize != 0 {
+		return nil, fmt.Errorf("chat index file %s has an unexpected length", path)
+	}
+
+	records := make([]indexRecord, 0, len(data)/indexRecordSize)
+	for offset := 0; offset < len(data); offset += indexRecordSize {
+		chunk := data[offset : offset+indexRecordSize]
+		records = append(records, indexRecord{
+			Offset:    binary.BigEndian.Uint64(chunk[0:8]),
+			Length:    binary.BigEndian.Uint32(chunk[8:12]),
+			Timestamp: int64(binary.BigEndian.Uint64(chunk[12:20])),
+		})
+	}
+	return records, nil
+}
+
+// readMessages reads back sessionID's messages from path, opening any
+// encrypted lines through s.encryption.
+func (s *Store) readMessages(sessionID, path string) ([]Message, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open chat session file: %w", err)
@@ -278,9 +983,9 @@ func readMessages(path string) ([]Message, error) {
 	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
 	var messages []Message
 	for scanner.Scan() {
-		var msg Message
-		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
-			return nil, fmt.Errorf("unable to decode chat message: %w", err)
+		msg, err := s.decodeLine(sessionID, scanner.Bytes())
+		if err != nil {
+			return nil, err
 		}
 		messages = append(messages, msg)
 	}
@@ -0,0 +1,68 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package health tracks per-backend failure state so a router can skip a
+// backend that just errored until it has had time to recover.
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracker records which keys are currently unhealthy and for how long. A key
+// identifies whatever the caller needs to distinguish as independently
+// healthy or unhealthy; a router with two routes sharing a provider name but
+// different endpoints should key them separately rather than by name alone.
+type Tracker struct {
+	mu        sync.Mutex
+	cooldown  time.Duration
+	now       func() time.Time
+	unhealthy map[string]time.Time
+}
+
+// NewTracker returns a Tracker that keeps a key marked unhealthy for
+// cooldown after each failure.
+func NewTracker(cooldown time.Duration) *Tracker {
+	return &Tracker{
+		cooldown:  cooldown,
+		now:       time.Now,
+		unhealthy: make(map[string]time.Time),
+	}
+}
+
+// MarkUnhealthy records a failure for key, making it ineligible until the
+// cooldown window elapses.
+func (t *Tracker) MarkUnhealthy(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.unhealthy[key] = t.now().Add(t.cooldown)
+}
+
+// IsHealthy reports whether key is currently eligible for traffic. A key
+// that has never failed, or whose cooldown has elapsed, is healthy; the
+// decayed entry is dropped so the map doesn't grow unbounded.
+func (t *Tracker) IsHealthy(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until, failed := t.unhealthy[key]
+	if !failed {
+		return true
+	}
+	if t.now().After(until) {
+		delete(t.unhealthy, key)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"os"
+	"strings"
+)
+
+// CanonicalName maps common aliases (vllm, lmstudio, gemini, ...) onto the
+// name the serving Provider is actually registered under.
+func CanonicalName(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "ollama":
+		return "ollama"
+	case "openai-compatible", "openai", "openai-compatible-chat", "vllm",
+		"lmstudio", "lm-studio", "llamacpp", "llama.cpp", "llama-cpp",
+		"textgen-webui", "text-generation-webui", "oobabooga":
+		return "openai-compatible"
+	case "anthropic", "claude":
+		return "anthropic"
+	case "google", "gemini":
+		return "google"
+	case "cohere":
+		return "cohere"
+	default:
+		return ""
+	}
+}
+
+// envVarsByName lists the environment variables checked, in order, for each
+// provider's API key when no --api-key flag is supplied.
+var envVarsByName = map[string][]string{
+	"openai-compatible": {"OPENAI_API_KEY"},
+	"anthropic":         {"ANTHROPIC_API_KEY"},
+	"google":            {"GOOGLE_API_KEY", "GEMINI_API_KEY"},
+	"cohere":            {"COHERE_API_KEY"},
+}
+
+// ResolveAPIKey returns explicit if set, otherwise the first non-empty value
+// among the environment variables conventionally used for name's API key.
+// Self-hosted providers such as "ollama" have no known env vars and resolve
+// to explicit (often empty) unchanged.
+func ResolveAPIKey(name, explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	for _, envVar := range envVarsByName[name] {
+		if value := os.Getenv(envVar); value != "" {
+			return value
+		}
+	}
+	return ""
+}
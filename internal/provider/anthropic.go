@@ -0,0 +1,188 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const anthropicDefaultBaseURL = "https://api.anthropic.com"
+const anthropicVersion = "2023-06-01"
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	Messages  []anthropicMessage `json:"messages"`
+	System    string             `json:"system,omitempty"`
+	MaxTokens int                `json:"max_tokens"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+// anthropicStreamEvent covers the handful of Messages API SSE event shapes
+// Stream cares about: message_start carries the prompt's input_tokens,
+// content_block_delta carries the text fragment, and message_delta carries
+// the cumulative output_tokens once generation finishes.
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Text string `json:"text"`
+	} `json:"delta"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// Anthropic talks to the Messages API at /v1/messages.
+type Anthropic struct {
+	Client *http.Client
+}
+
+// NewAnthropic returns a Provider that sends requests through client.
+func NewAnthropic(client *http.Client) *Anthropic {
+	return &Anthropic{Client: client}
+}
+
+func (a *Anthropic) Name() string { return "anthropic" }
+
+func (a *Anthropic) Complete(ctx context.Context, req Request) (string, Usage, error) {
+	resp, err := a.send(ctx, req, false)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var decoded anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", Usage{}, fmt.Errorf("decode anthropic response: %w", err)
+	}
+	if len(decoded.Content) == 0 {
+		return "", Usage{}, fmt.Errorf("anthropic response missing content")
+	}
+	return decoded.Content[0].Text, anthropicToUsage(decoded.Usage), nil
+}
+
+func (a *Anthropic) Stream(ctx context.Context, req Request, w io.Writer) (Usage, error) {
+	resp, err := a.send(ctx, req, true)
+	if err != nil {
+		return Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var usage Usage
+	err = consumeSSE(resp.Body, w, func(data string) (string, bool, error) {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return "", false, fmt.Errorf("decode anthropic stream event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "message_stop":
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+			return "", true, nil
+		}
+		return event.Delta.Text, false, nil
+	})
+	return usage, err
+}
+
+// anthropicToUsage converts a Messages API usage block into a
+// provider-agnostic Usage.
+func anthropicToUsage(u anthropicUsage) Usage {
+	return Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+func (a *Anthropic) send(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	system, messages := splitSystemMessage(req.Messages)
+	anthropicMessages := make([]anthropicMessage, len(messages))
+	for i, msg := range messages {
+		anthropicMessages[i] = anthropicMessage{Role: msg.Role, Content: msg.Content}
+	}
+
+	body, err := json.Marshal(anthropicRequest{
+		Model:     req.Model,
+		Messages:  anthropicMessages,
+		System:    system,
+		MaxTokens: 4096,
+		Stream:    stream,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal anthropic request: %w", err)
+	}
+
+	baseURL := req.BaseURL
+	if baseURL == "" {
+		baseURL = anthropicDefaultBaseURL
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/v1/messages", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("build anthropic request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	httpReq.Header.Set("x-api-key", req.APIKey)
+
+	resp, err := a.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send anthropic request: %w", err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		errBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("anthropic error: %w", &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(errBody))})
+	}
+	return resp, nil
+}
+
+// splitSystemMessage pulls a leading "system" role message out of messages,
+// since Anthropic takes the system prompt as a top-level field rather than a
+// message in the conversation.
+func splitSystemMessage(messages []Message) (string, []Message) {
+	if len(messages) > 0 && messages[0].Role == "system" {
+		return messages[0].Content, messages[1:]
+	}
+	return "", messages
+}
@@ -0,0 +1,184 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const cohereDefaultBaseURL = "https://api.cohere.com"
+
+type cohereMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type cohereRequest struct {
+	Model    string          `json:"model"`
+	Messages []cohereMessage `json:"messages"`
+	Stream   bool            `json:"stream,omitempty"`
+}
+
+type cohereUsage struct {
+	Tokens struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"tokens"`
+}
+
+type cohereResponse struct {
+	Message struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"message"`
+	Usage cohereUsage `json:"usage"`
+}
+
+// cohereStreamEvent covers the two v2 Chat API stream event shapes Stream
+// cares about: content-delta carries a text fragment, and message-end
+// carries the final usage once generation finishes.
+type cohereStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Message struct {
+			Content struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"message"`
+		Usage cohereUsage `json:"usage"`
+	} `json:"delta"`
+}
+
+// Cohere talks to the v2 Chat API at /v2/chat, authenticating with a bearer
+// token.
+type Cohere struct {
+	Client *http.Client
+}
+
+// NewCohere returns a Provider that sends requests through client.
+func NewCohere(client *http.Client) *Cohere {
+	return &Cohere{Client: client}
+}
+
+func (c *Cohere) Name() string { return "cohere" }
+
+func (c *Cohere) Complete(ctx context.Context, req Request) (string, Usage, error) {
+	resp, err := c.send(ctx, req, false)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var decoded cohereResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", Usage{}, fmt.Errorf("decode cohere response: %w", err)
+	}
+	if len(decoded.Message.Content) == 0 {
+		return "", Usage{}, fmt.Errorf("cohere response missing content")
+	}
+	return decoded.Message.Content[0].Text, cohereToUsage(decoded.Usage), nil
+}
+
+func (c *Cohere) Stream(ctx context.Context, req Request, w io.Writer) (Usage, error) {
+	resp, err := c.send(ctx, req, true)
+	if err != nil {
+		return Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var usage Usage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var event cohereStreamEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return Usage{}, fmt.Errorf("decode cohere stream event: %w", err)
+		}
+		switch event.Type {
+		case "content-delta":
+			text := event.Delta.Message.Content.Text
+			if text == "" {
+				continue
+			}
+			if _, err := io.WriteString(w, text); err != nil {
+				return Usage{}, fmt.Errorf("write cohere stream chunk: %w", err)
+			}
+		case "message-end":
+			usage = cohereToUsage(event.Delta.Usage)
+		}
+	}
+	return usage, scanner.Err()
+}
+
+// cohereToUsage converts a v2 Chat API usage block into a provider-agnostic
+// Usage.
+func cohereToUsage(u cohereUsage) Usage {
+	return Usage{
+		PromptTokens:     u.Tokens.InputTokens,
+		CompletionTokens: u.Tokens.OutputTokens,
+		TotalTokens:      u.Tokens.InputTokens + u.Tokens.OutputTokens,
+	}
+}
+
+func (c *Cohere) send(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	messages := make([]cohereMessage, len(req.Messages))
+	for i, msg := range req.Messages {
+		messages[i] = cohereMessage{Role: msg.Role, Content: msg.Content}
+	}
+
+	body, err := json.Marshal(cohereRequest{
+		Model:    req.Model,
+		Messages: messages,
+		Stream:   stream,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal cohere request: %w", err)
+	}
+
+	baseURL := req.BaseURL
+	if baseURL == "" {
+		baseURL = cohereDefaultBaseURL
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/v2/chat", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("build cohere request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
+
+	resp, err := c.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send cohere request: %w", err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		errBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("cohere error: %w", &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(errBody))})
+	}
+	return resp, nil
+}
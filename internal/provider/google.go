@@ -0,0 +1,175 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const googleDefaultBaseURL = "https://generativelanguage.googleapis.com"
+
+type googlePart struct {
+	Text string `json:"text"`
+}
+
+type googleContent struct {
+	Role  string       `json:"role"`
+	Parts []googlePart `json:"parts"`
+}
+
+type googleRequest struct {
+	Contents          []googleContent `json:"contents"`
+	SystemInstruction *googleContent  `json:"systemInstruction,omitempty"`
+}
+
+type googleUsage struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type googleResponse struct {
+	Candidates []struct {
+		Content googleContent `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata googleUsage `json:"usageMetadata"`
+}
+
+// Google talks to the Gemini API's generateContent / streamGenerateContent
+// endpoints, authenticating with an API key query parameter.
+type Google struct {
+	Client *http.Client
+}
+
+// NewGoogle returns a Provider that sends requests through client.
+func NewGoogle(client *http.Client) *Google {
+	return &Google{Client: client}
+}
+
+func (g *Google) Name() string { return "google" }
+
+func (g *Google) Complete(ctx context.Context, req Request) (string, Usage, error) {
+	resp, err := g.send(ctx, req, "generateContent", false)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var decoded googleResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", Usage{}, fmt.Errorf("decode google response: %w", err)
+	}
+	if len(decoded.Candidates) == 0 || len(decoded.Candidates[0].Content.Parts) == 0 {
+		return "", Usage{}, fmt.Errorf("google response missing candidates")
+	}
+	return decoded.Candidates[0].Content.Parts[0].Text, googleToUsage(decoded.UsageMetadata), nil
+}
+
+func (g *Google) Stream(ctx context.Context, req Request, w io.Writer) (Usage, error) {
+	resp, err := g.send(ctx, req, "streamGenerateContent", true)
+	if err != nil {
+		return Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var usage Usage
+	err = consumeSSE(resp.Body, w, func(data string) (string, bool, error) {
+		var chunk googleResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", false, fmt.Errorf("decode google stream chunk: %w", err)
+		}
+		// Gemini reports usageMetadata as a running total on every chunk,
+		// not just the last one, so the latest chunk seen always has the
+		// final counts.
+		usage = googleToUsage(chunk.UsageMetadata)
+		if len(chunk.Candidates) == 0 || len(chunk.Candidates[0].Content.Parts) == 0 {
+			return "", false, nil
+		}
+		return chunk.Candidates[0].Content.Parts[0].Text, false, nil
+	})
+	return usage, err
+}
+
+// googleToUsage converts a Gemini usageMetadata block into a
+// provider-agnostic Usage.
+func googleToUsage(u googleUsage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount,
+		TotalTokens:      u.TotalTokenCount,
+	}
+}
+
+func (g *Google) send(ctx context.Context, req Request, method string, stream bool) (*http.Response, error) {
+	system, messages := splitSystemMessage(req.Messages)
+
+	contents := make([]googleContent, len(messages))
+	for i, msg := range messages {
+		contents[i] = googleContent{Role: googleRole(msg.Role), Parts: []googlePart{{Text: msg.Content}}}
+	}
+
+	payload := googleRequest{Contents: contents}
+	if system != "" {
+		payload.SystemInstruction = &googleContent{Parts: []googlePart{{Text: system}}}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal google request: %w", err)
+	}
+
+	baseURL := req.BaseURL
+	if baseURL == "" {
+		baseURL = googleDefaultBaseURL
+	}
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:%s", strings.TrimRight(baseURL, "/"), req.Model, method)
+	query := url.Values{"key": {req.APIKey}}
+	if stream {
+		query.Set("alt", "sse")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"?"+query.Encode(), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("build google request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send google request: %w", err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		errBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("google error: %w", &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(errBody))})
+	}
+	return resp, nil
+}
+
+// googleRole maps the provider-agnostic "assistant" role to Gemini's "model"
+// role; every other role passes through unchanged.
+func googleRole(role string) string {
+	if role == "assistant" {
+		return "model"
+	}
+	return role
+}
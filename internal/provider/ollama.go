@@ -0,0 +1,164 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type ollamaRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	Stream bool   `json:"stream"`
+}
+
+type ollamaResponse struct {
+	Response        string `json:"response"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+// Ollama talks to a self-hosted Ollama server's /api/generate endpoint,
+// which takes a single flattened prompt rather than a messages array.
+type Ollama struct {
+	Client *http.Client
+}
+
+// NewOllama returns a Provider that sends requests through client.
+func NewOllama(client *http.Client) *Ollama {
+	return &Ollama{Client: client}
+}
+
+func (o *Ollama) Name() string { return "ollama" }
+
+func (o *Ollama) Complete(ctx context.Context, req Request) (string, Usage, error) {
+	body, err := json.Marshal(ollamaRequest{
+		Model:  req.Model,
+		Prompt: flattenPrompt(req.Messages),
+		Stream: false,
+	})
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("marshal ollama request: %w", err)
+	}
+
+	resp, err := o.do(ctx, req.BaseURL, body)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var decoded ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", Usage{}, fmt.Errorf("decode ollama response: %w", err)
+	}
+	return decoded.Response, ollamaUsage(decoded), nil
+}
+
+func (o *Ollama) Stream(ctx context.Context, req Request, w io.Writer) (Usage, error) {
+	body, err := json.Marshal(ollamaRequest{
+		Model:  req.Model,
+		Prompt: flattenPrompt(req.Messages),
+		Stream: true,
+	})
+	if err != nil {
+		return Usage{}, fmt.Errorf("marshal ollama request: %w", err)
+	}
+
+	resp, err := o.do(ctx, req.BaseURL, body)
+	if err != nil {
+		return Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var usage Usage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var chunk ollamaResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return Usage{}, fmt.Errorf("decode ollama stream chunk: %w", err)
+		}
+		if chunk.Response != "" {
+			if _, err := io.WriteString(w, chunk.Response); err != nil {
+				return Usage{}, fmt.Errorf("write ollama stream chunk: %w", err)
+			}
+		}
+		if chunk.Done {
+			usage = ollamaUsage(chunk)
+			break
+		}
+	}
+	return usage, scanner.Err()
+}
+
+// ollamaUsage converts the prompt/eval counts Ollama reports on its final
+// chunk into a provider-agnostic Usage.
+func ollamaUsage(resp ollamaResponse) Usage {
+	return Usage{
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+	}
+}
+
+func (o *Ollama) do(ctx context.Context, baseURL string, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/api/generate", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("build ollama request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send ollama request: %w", err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		errBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama error: %w", &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(errBody))})
+	}
+	return resp, nil
+}
+
+// flattenPrompt joins a messages array into the single prompt string Ollama's
+// /api/generate endpoint expects.
+func flattenPrompt(messages []Message) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	if len(messages) == 1 && messages[0].Role == "user" {
+		return messages[0].Content
+	}
+	var b strings.Builder
+	for i, msg := range messages {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s: %s", strings.ToUpper(msg.Role), msg.Content)
+	}
+	return b.String()
+}
@@ -0,0 +1,193 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	Stream        bool                 `json:"stream"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage openAIUsage `json:"usage"`
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// OpenAICompatible talks to any server implementing the OpenAI
+// /v1/chat/completions API: vLLM, LM Studio, llama.cpp's server, and
+// text-generation-webui all qualify.
+type OpenAICompatible struct {
+	Client *http.Client
+}
+
+// NewOpenAICompatible returns a Provider that sends requests through client.
+func NewOpenAICompatible(client *http.Client) *OpenAICompatible {
+	return &OpenAICompatible{Client: client}
+}
+
+func (o *OpenAICompatible) Name() string { return "openai-compatible" }
+
+func (o *OpenAICompatible) Complete(ctx context.Context, req Request) (string, Usage, error) {
+	body, err := json.Marshal(openAIRequest{
+		Model:    req.Model,
+		Messages: toOpenAIMessages(req.Messages),
+	})
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("marshal openai-compatible request: %w", err)
+	}
+
+	resp, err := o.do(ctx, req, body)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var decoded openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", Usage{}, fmt.Errorf("decode openai-compatible response: %w", err)
+	}
+	if len(decoded.Choices) == 0 {
+		return "", Usage{}, fmt.Errorf("openai-compatible response missing choices")
+	}
+	return decoded.Choices[0].Message.Content, toProviderUsage(decoded.Usage), nil
+}
+
+func (o *OpenAICompatible) Stream(ctx context.Context, req Request, w io.Writer) (Usage, error) {
+	body, err := json.Marshal(openAIRequest{
+		Model:         req.Model,
+		Messages:      toOpenAIMessages(req.Messages),
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return Usage{}, fmt.Errorf("marshal openai-compatible request: %w", err)
+	}
+
+	resp, err := o.do(ctx, req, body)
+	if err != nil {
+		return Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var usage Usage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return Usage{}, fmt.Errorf("decode openai-compatible stream chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = toProviderUsage(*chunk.Usage)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		if delta == "" {
+			continue
+		}
+		if _, err := io.WriteString(w, delta); err != nil {
+			return Usage{}, fmt.Errorf("write openai-compatible stream chunk: %w", err)
+		}
+	}
+	return usage, scanner.Err()
+}
+
+func (o *OpenAICompatible) do(ctx context.Context, req Request, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(req.BaseURL, "/")+"/v1/chat/completions", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("build openai-compatible request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if req.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
+	}
+
+	resp, err := o.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("send openai-compatible request: %w", err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		errBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("openai-compatible error: %w", &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(errBody))})
+	}
+	return resp, nil
+}
+
+func toOpenAIMessages(messages []Message) []openAIMessage {
+	converted := make([]openAIMessage, len(messages))
+	for i, msg := range messages {
+		converted[i] = openAIMessage{Role: msg.Role, Content: msg.Content}
+	}
+	return converted
+}
+
+// toProviderUsage converts an OpenAI-shaped usage block into a
+// provider-agnostic Usage.
+func toProviderUsage(u openAIUsage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package provider abstracts over self-hosted and hosted LLM backends behind
+// a single Provider interface, so callers can complete or stream a
+// conversation without knowing whether it lands on Ollama, an
+// OpenAI-compatible server, or a cloud API.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Message is a single turn in a provider-agnostic conversation.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request carries everything a Provider needs to complete or stream a
+// conversation turn.
+type Request struct {
+	Model    string
+	Messages []Message
+	APIKey   string
+	BaseURL  string
+}
+
+// Usage reports the token counts a provider's reply included, when it
+// reports them at all. A zero Usage means the backend didn't report counts,
+// not that the call used no tokens.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Provider is a backend capable of completing or streaming chat turns,
+// whether self-hosted (Ollama, vLLM, ...) or a hosted cloud API (Anthropic,
+// Google, Cohere, ...).
+type Provider interface {
+	// Name is the identifier this provider is registered and looked up
+	// under, e.g. "ollama" or "anthropic".
+	Name() string
+	// Complete sends req and returns the full assistant reply along with
+	// any usage the backend reported for it.
+	Complete(ctx context.Context, req Request) (string, Usage, error)
+	// Stream sends req and writes the assistant reply to w incrementally
+	// as it arrives, returning any usage the backend reported once the
+	// stream ends.
+	Stream(ctx context.Context, req Request, w io.Writer) (Usage, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{}
+)
+
+// Register adds p to the registry under p.Name(), overwriting any provider
+// previously registered under the same name.
+func Register(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[p.Name()] = p
+}
+
+// Get looks up a registered provider by name.
+func Get(name string) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider %q (supported: %s)", name, namesLocked())
+	}
+	return p, nil
+}
+
+// Names returns the sorted names of every registered provider.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return namesLocked()
+}
+
+func namesLocked() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StatusError is returned when a provider's remote endpoint responds with a
+// non-2xx status, so callers such as a router can decide whether the
+// failure is worth failing over from.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+// Retryable reports whether err represents a provider failure that a caller
+// should fail over to the next provider for: 401/403/429/5xx responses, or
+// any error that isn't a StatusError at all (connection failures, timeouts).
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+			return true
+		}
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
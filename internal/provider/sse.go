@@ -0,0 +1,54 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// consumeSSE reads Server-Sent Events (`data: {...}` lines terminated by a
+// blank line) from r, handing each event's data payload to decode. decode
+// returns the text fragment to write, whether the stream is done, and any
+// decoding error. A literal "[DONE]" payload always ends the stream.
+func consumeSSE(r io.Reader, w io.Writer, decode func(data string) (text string, done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+		text, done, err := decode(data)
+		if err != nil {
+			return err
+		}
+		if text != "" {
+			if _, err := io.WriteString(w, text); err != nil {
+				return fmt.Errorf("write stream chunk: %w", err)
+			}
+		}
+		if done {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
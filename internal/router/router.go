@@ -0,0 +1,103 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package router dispatches a prompt to the first healthy provider in an
+// ordered fallback chain, so a mixed fleet of self-hosted and cloud backends
+// can stand in for one another when one is down or rate-limited.
+package router
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/googleapis/genai-toolbox/internal/health"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultCooldown = 30 * time.Second
+
+// Route is one entry in an ordered fallback chain: a named, registered
+// provider plus the model and connection details to call it with.
+type Route struct {
+	Name    string `yaml:"name"`
+	Model   string `yaml:"model"`
+	BaseURL string `yaml:"baseUrl"`
+	APIKey  string `yaml:"apiKey"`
+}
+
+// key identifies route for health tracking. Name alone isn't enough: a mixed
+// local/cloud fleet commonly repeats the same provider name (e.g. two
+// "openai-compatible" routes) at different BaseURLs, and those must be
+// tracked as independently healthy or unhealthy.
+func (r Route) key() string {
+	return r.Name + "\x00" + r.BaseURL
+}
+
+// Config is the on-disk shape of a --config routes.yaml file.
+type Config struct {
+	Cooldown time.Duration `yaml:"cooldown"`
+	Routes   []Route       `yaml:"routes"`
+}
+
+// LoadConfig reads and parses a routes.yaml file, defaulting Cooldown when
+// it is unset.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to read router config: %w", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("unable to parse router config: %w", err)
+	}
+	if len(cfg.Routes) == 0 {
+		return Config{}, fmt.Errorf("router config has no routes")
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultCooldown
+	}
+	return cfg, nil
+}
+
+// Router walks Routes in order and hands out the first one its health
+// tracker still considers healthy.
+type Router struct {
+	routes  []Route
+	tracker *health.Tracker
+}
+
+// New returns a Router over routes, marking a route unhealthy for cooldown
+// each time MarkFailure is called for it. Two routes that share a Name but
+// differ in BaseURL are tracked independently.
+func New(routes []Route, cooldown time.Duration) *Router {
+	return &Router{routes: routes, tracker: health.NewTracker(cooldown)}
+}
+
+// Next returns the first currently healthy route, in configured order.
+func (r *Router) Next() (Route, bool) {
+	for _, route := range r.routes {
+		if r.tracker.IsHealthy(route.key()) {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// MarkFailure records that route just failed and should be skipped until its
+// cooldown elapses. Only this route is affected, even if another route
+// shares its Name at a different BaseURL.
+func (r *Router) MarkFailure(route Route) {
+	r.tracker.MarkUnhealthy(route.key())
+}
@@ -34,16 +34,77 @@ func (s *Server) recordChatMessage(ctx context.Context, sessionID, toolsetName s
 		direction = chats.DirectionNotification
 	}
 
-	if err := s.chatStore.RecordMessage(ctx, sessionID, toolsetName, direction, payload); err != nil {
+	if err := s.chatStore.RecordMessage(ctx, sessionID, toolsetName, direction, payload, nil); err != nil {
 		s.logger.DebugContext(ctx, fmt.Sprintf("unable to record chat message: %v", err))
 	}
 }
 
-func (s *Server) recordChatResponse(ctx context.Context, sessionID, toolsetName string, payload []byte) {
+// recordChatResponse records payload as a response, attaching usage if the
+// backend reported token counts for it.
+func (s *Server) recordChatResponse(ctx context.Context, sessionID, toolsetName string, payload []byte, usage *chats.Usage) {
 	if s == nil || s.chatStore == nil {
 		return
 	}
-	if err := s.chatStore.RecordMessage(ctx, sessionID, toolsetName, chats.DirectionResponse, payload); err != nil {
+	if err := s.chatStore.RecordMessage(ctx, sessionID, toolsetName, chats.DirectionResponse, payload, usage); err != nil {
 		s.logger.DebugContext(ctx, fmt.Sprintf("unable to record chat response: %v", err))
 	}
 }
+
+// HandleChatFork implements the "chats/fork" notification: it forks
+// sessionID at fromMessageID into a new session and records the fork as a
+// notification on the original session so ExportAll captures why the new
+// session exists. The MCP method dispatcher (outside this package, alongside
+// the other "tools/*" and "chats/*" routes) is responsible for recognizing
+// the "chats/fork" method name and calling this handler with its params.
+func (s *Server) HandleChatFork(ctx context.Context, sessionID, fromMessageID string) (string, error) {
+	if s == nil || s.chatStore == nil {
+		return "", fmt.Errorf("chat storage is not configured")
+	}
+	newSessionID, err := s.chatStore.Fork(sessionID, fromMessageID)
+	if err != nil {
+		return "", fmt.Errorf("unable to fork chat session: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "chats/fork",
+		"params": map[string]string{
+			"fromMessageId": fromMessageID,
+			"toSessionId":   newSessionID,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal fork notification: %w", err)
+	}
+	s.recordChatMessage(ctx, sessionID, "", payload)
+	return newSessionID, nil
+}
+
+// HandleChatEdit implements the "chats/edit" notification: it edits
+// messageID within sessionID by forking and appending newPayload as a new
+// leaf in its place, then records the edit as a notification on the
+// original session. As with HandleChatFork, the MCP method dispatcher is
+// responsible for routing an incoming "chats/edit" method to this handler.
+func (s *Server) HandleChatEdit(ctx context.Context, sessionID, messageID string, newPayload []byte) (string, error) {
+	if s == nil || s.chatStore == nil {
+		return "", fmt.Errorf("chat storage is not configured")
+	}
+	newSessionID, err := s.chatStore.EditAndFork(sessionID, messageID, newPayload)
+	if err != nil {
+		return "", fmt.Errorf("unable to edit chat message: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "chats/edit",
+		"params": map[string]string{
+			"messageId":   messageID,
+			"toSessionId": newSessionID,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal edit notification: %w", err)
+	}
+	s.recordChatMessage(ctx, sessionID, "", payload)
+	return newSessionID, nil
+}